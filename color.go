@@ -29,6 +29,13 @@ func makeColorInfo(c color.Color) (ci *colorInfo) {
 	}
 }
 
+// rgbFloats converts a color.Color to its RGB components, each
+// normalized to the 0-1 range, regardless of its underlying color model.
+func rgbFloats(c color.Color) (r, g, b float64) {
+	cr, cg, cb, _ := c.RGBA()
+	return float64(cr) / 0xFFFF, float64(cg) / 0xFFFF, float64(cb) / 0xFFFF
+}
+
 func makeParams(mv uint, v ...uint) []float64 {
 	t := make([]float64, len(v))
 	for i := 0; i < len(v); i++ {
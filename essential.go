@@ -26,6 +26,12 @@ eoCircle(0.40, 0.56, 0.02, 0.03, "f") + eoCircle(0.60, 0.56, 0.02, 0.03, "f")
 const eoMouthCode = // mouth=true, mouthshape=smile
 `0.40 0.48 m 0.45 0.45 0.55 0.45 0.60 0.48 c S`
 
+const eoMouthFrownCode = // mouth=true, mouthshape=frown
+`0.40 0.45 m 0.45 0.48 0.55 0.48 0.60 0.45 c S`
+
+const eoMouthNeutralCode = // mouth=true, mouthshape=neutral
+`0.40 0.465 m 0.60 0.465 l S`
+
 const eoHatCode = // hat=true
 `0.58 0.90 m 0.77 0.81 l 0.74 0.61 l 0.66 0.60 0.50 0.66 0.46 0.72 c
 0.58 0.90 l b`
@@ -61,19 +67,136 @@ eoCircle(0.07, 0.28, 0.04, 0.04, "s") + eoCircle(0.08, 0.68, 0.04, 0.04, "s") +
 
 //--------------------------------------
 
-func essentialCode(p page, scale float64) string {
+// snowmanXObjectName is the resource name under which the shared
+// black-ink snowman drawing is installed as a Form XObject in the page
+// resources dictionary, when reuse is enabled (see Doc.SetSnowmanReuse).
+const snowmanXObjectName = "/Sm1"
+
+// ocWrap wraps code (which must end with a newline) in "/OC /MCxxx BDC
+// ... EMC" marked-content operators if part is one of the enabled
+// layers (see Doc.EnableLayers); otherwise code is returned unchanged.
+func ocWrap(part string, layers []string, code string) string {
+	if !layerEnabled(part, layers) {
+		return code
+	}
+	return fmt.Sprintf("/OC /MC%s BDC\n%sEMC\n", part, code)
+}
+
+// snowmanBodyCode is the fixed black-ink drawing common to every
+// snowman: the body, eyes, mouth, hat, arms, buttons and falling snow.
+// It does not depend on the muffler color, which is why it can be
+// factored out into a single shared Form XObject. Each part is wrapped
+// in its own Optional Content Group marked-content sequence when it is
+// one of the enabled layers.
+func snowmanBodyCode(layers []string) string {
 	buf := new(bytes.Buffer)
-	ci := makeColorInfo(p.muffler)
 	fmt.Fprintln(buf, "0 G 0 g 1 j 1 J 0.01389 w")
-	fmt.Fprintln(buf, eoBodyCode)
-	fmt.Fprint(buf, eoEyesCode)
-	fmt.Fprintln(buf, eoMouthCode)
-	fmt.Fprintln(buf, eoHatCode)
-	fmt.Fprintln(buf, eoArmsCode)
-	fmt.Fprint(buf, eoButtonsCode)
-	fmt.Fprint(buf, eoSnowCode)
+	fmt.Fprint(buf, ocWrap("Body", layers, eoBodyCode+"\n"))
+	fmt.Fprint(buf, ocWrap("Eyes", layers, eoEyesCode))
+	fmt.Fprint(buf, ocWrap("Mouth", layers, eoMouthCode+"\n"))
+	fmt.Fprint(buf, ocWrap("Hat", layers, eoHatCode+"\n"))
+	fmt.Fprint(buf, ocWrap("Arms", layers, eoArmsCode+"\n"))
+	fmt.Fprint(buf, ocWrap("Buttons", layers, eoButtonsCode))
+	fmt.Fprint(buf, ocWrap("Snow", layers, eoSnowCode))
+	return buf.String()
+}
+
+func essentialCode(p page, scale float64, layers []string) string {
+	buf := new(bytes.Buffer)
+	ci := makeColorInfo(p.muffler)
+	fmt.Fprint(buf, snowmanBodyCode(layers))
+	fmt.Fprintln(buf, ci.pdfCode(false), ci.pdfCode(true))
+	fmt.Fprint(buf, ocWrap("Muffler", layers, eoMufflerCode+"\n"))
+	return buf.String()
+}
+
+// essentialCodeReused is the per-page content stream used when the
+// shared snowman body is factored into a Form XObject: it only sets the
+// muffler colour, invokes the XObject, and draws the muffler path.
+func essentialCodeReused(p page, layers []string) string {
+	buf := new(bytes.Buffer)
+	ci := makeColorInfo(p.muffler)
+	fmt.Fprintln(buf, ci.pdfCode(false), ci.pdfCode(true))
+	fmt.Fprintln(buf, snowmanXObjectName, "Do")
+	fmt.Fprint(buf, ocWrap("Muffler", layers, eoMufflerCode+"\n"))
+	return buf.String()
+}
+
+// essentialCodeGradient is the per-page content stream used when the
+// page's muffler is a Gradient: the muffler path is painted through the
+// shading pattern "/P1" (set up in the page's own resources dictionary)
+// instead of a flat fill/stroke color.
+func essentialCodeGradient(reuse bool, layers []string) string {
+	buf := new(bytes.Buffer)
+	if reuse {
+		fmt.Fprintln(buf, snowmanXObjectName, "Do")
+	} else {
+		fmt.Fprint(buf, snowmanBodyCode(layers))
+	}
+	fmt.Fprintln(buf, "/Pattern cs /Pattern CS")
+	fmt.Fprintln(buf, "/P1 scn /P1 SCN")
+	fmt.Fprint(buf, ocWrap("Muffler", layers, eoMufflerCode+"\n"))
+	return buf.String()
+}
+
+// mouthCode selects the path code for the given mouth shape.
+func mouthCode(shape MouthShape) string {
+	switch shape {
+	case MouthFrown:
+		return eoMouthFrownCode
+	case MouthNeutral:
+		return eoMouthNeutralCode
+	default:
+		return eoMouthCode
+	}
+}
+
+// essentialCodeStyled is the per-page content stream used when a page is
+// added with Doc.AddPageStyled: each anatomical part is emitted only if
+// enabled by style, and a caption (if any) is set in the standard font
+// "/F1" afterwards. useXObject tells whether style happens to match the
+// full default drawing, so that the shared snowman Form XObject can be
+// invoked instead of drawing the body inline.
+func essentialCodeStyled(p page, style SnowmanStyle, useXObject bool, layers []string) string {
+	buf := new(bytes.Buffer)
+	ci := makeColorInfo(p.muffler)
+	if useXObject {
+		fmt.Fprintln(buf, snowmanXObjectName, "Do")
+	} else {
+		fmt.Fprintln(buf, "0 G 0 g 1 j 1 J 0.01389 w")
+		fmt.Fprint(buf, ocWrap("Body", layers, eoBodyCode+"\n"))
+		if style.Eyes {
+			fmt.Fprint(buf, ocWrap("Eyes", layers, eoEyesCode))
+		}
+		if style.Mouth {
+			fmt.Fprint(buf, ocWrap("Mouth", layers, mouthCode(style.MouthShape)+"\n"))
+		}
+		if style.Hat {
+			fmt.Fprint(buf, ocWrap("Hat", layers, eoHatCode+"\n"))
+		}
+		if style.Arms {
+			fmt.Fprint(buf, ocWrap("Arms", layers, eoArmsCode+"\n"))
+		}
+		if style.Buttons {
+			fmt.Fprint(buf, ocWrap("Buttons", layers, eoButtonsCode))
+		}
+		if style.Snow {
+			fmt.Fprint(buf, ocWrap("Snow", layers, eoSnowCode))
+		}
+	}
 	fmt.Fprintln(buf, ci.pdfCode(false), ci.pdfCode(true))
-	fmt.Fprintln(buf, eoMufflerCode)
+	fmt.Fprint(buf, ocWrap("Muffler", layers, eoMufflerCode+"\n"))
+	if style.Caption != "" {
+		fmt.Fprint(buf, captionCode(style.Caption))
+	}
+	return buf.String()
+}
+
+// captionCode renders a caption string near the bottom of the 0-1 unit
+// square, in the standard Helvetica font exposed as "/F1".
+func captionCode(caption string) string {
+	buf := new(bytes.Buffer)
+	fmt.Fprintln(buf, "BT 0 0 0 rg /F1 0.09 Tf 0.05 0.02 Td", pdfTextStr(caption), "Tj ET")
 	return buf.String()
 }
 
@@ -0,0 +1,79 @@
+// Copyright (c) 2018 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package scpdf
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+)
+
+// Gradient represents a two-color gradient muffler, used in place of a
+// flat color.Color with Doc.AddPageGradient. The concrete types are
+// LinearGradient (axial) and RadialGradient (radial).
+type Gradient interface {
+	shadingCoords() (shadingType int, coords []float64)
+	colors() (c1, c2 color.Color)
+}
+
+// LinearGradient is a Gradient that interpolates linearly between two
+// colors along the line from (X1, Y1) to (X2, Y2), given in the same
+// 0-1 unit square used for the rest of the snowman drawing.
+type LinearGradient struct {
+	C1, C2         color.Color
+	X1, Y1, X2, Y2 float64
+}
+
+func (g LinearGradient) shadingCoords() (int, []float64) {
+	return 2, []float64{g.X1, g.Y1, g.X2, g.Y2}
+}
+
+func (g LinearGradient) colors() (color.Color, color.Color) {
+	return g.C1, g.C2
+}
+
+// RadialGradient is a Gradient that interpolates between two colors
+// from a circle centered at (X1, Y1) with radius R1 to a circle
+// centered at (X2, Y2) with radius R2, given in the same 0-1 unit
+// square used for the rest of the snowman drawing.
+type RadialGradient struct {
+	C1, C2                 color.Color
+	X1, Y1, R1, X2, Y2, R2 float64
+}
+
+func (g RadialGradient) shadingCoords() (int, []float64) {
+	return 3, []float64{g.X1, g.Y1, g.R1, g.X2, g.Y2, g.R2}
+}
+
+func (g RadialGradient) colors() (color.Color, color.Color) {
+	return g.C1, g.C2
+}
+
+// patternDict builds the content of a shading pattern object
+// (/PatternType 2, with an inline shading dictionary) that paints the
+// given gradient. matrixNums are the six numbers of the PDF matrix
+// (without the trailing "cm") that aligns pattern space with the 0-1
+// unit square of the page the pattern is used on.
+func patternDict(g Gradient, matrixNums string) string {
+	shadingType, coords := g.shadingCoords()
+	c1, c2 := g.colors()
+	r1, g1, b1 := rgbFloats(c1)
+	r2, g2, b2 := rgbFloats(c2)
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "<</Type/Pattern/PatternType 2/Matrix[%s]/Shading", matrixNums)
+	fmt.Fprintf(buf, "<</ShadingType %d/ColorSpace/DeviceRGB/Coords[", shadingType)
+	for i, c := range coords {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(realStr(c))
+	}
+	fmt.Fprintf(buf, "]/Function<</FunctionType 2/Domain[0 1]/C0[%s %s %s]/C1[%s %s %s]/N 1>>",
+		realStr(r1), realStr(g1), realStr(b1), realStr(r2), realStr(g2), realStr(b2))
+	buf.WriteString("/Extend[true true]>>>>")
+	return buf.String()
+}
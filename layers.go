@@ -0,0 +1,72 @@
+// Copyright (c) 2018 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package scpdf
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// layerPartNames lists, in display order, the anatomical parts of a
+// snowman that can be turned into Optional Content Groups (layers) via
+// Doc.EnableLayers.
+var layerPartNames = []string{
+	"Body", "Eyes", "Mouth", "Hat", "Arms", "Buttons", "Snow", "Muffler",
+}
+
+// EnableLayers turns the given anatomical parts into Optional Content
+// Groups: PDF viewers such as Acrobat or Okular can then show or hide
+// each part independently. The recognized part names are "Body",
+// "Eyes", "Mouth", "Hat", "Arms", "Buttons", "Snow" and "Muffler"; all
+// of them default to visible. Parts not named here are always drawn
+// and cannot be toggled.
+func (d *Doc) EnableLayers(names ...string) error {
+	if d.frozen {
+		return errFrozen()
+	}
+	given := make(map[string]bool, len(names))
+	for _, n := range names {
+		given[n] = true
+	}
+	layers := make([]string, 0, len(names))
+	for _, part := range layerPartNames {
+		if given[part] {
+			layers = append(layers, part)
+			delete(given, part)
+		}
+	}
+	for n := range given {
+		return fmt.Errorf("unknown snowman part %q", n)
+	}
+	d.layers = layers
+	return nil
+}
+
+// layerEnabled reports whether part is one of the enabled layers.
+func layerEnabled(part string, layers []string) bool {
+	for _, n := range layers {
+		if n == part {
+			return true
+		}
+	}
+	return false
+}
+
+// propertiesChunk builds the "/Properties<<...>>" resources dictionary
+// entry that exposes each enabled layer's Optional Content Group under
+// its "/MCxxx" name, or nil if no layers are enabled.
+func propertiesChunk(layers []string, ocgIds map[string]pdfId) []byte {
+	if len(layers) == 0 {
+		return nil
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteString("/Properties<<")
+	for _, name := range layers {
+		fmt.Fprintf(buf, "/MC%s %v", name, ocgIds[name])
+	}
+	buf.WriteString(">>")
+	return buf.Bytes()
+}
@@ -92,6 +92,15 @@ type pdfDoc struct {
 
 	title, author, subject          string
 	producer, creator, creationDate string
+
+	ocgOrder []string
+	ocgIds   map[string]pdfId
+}
+
+// setLayers records the Optional Content Groups to be declared in the
+// document catalog's /OCProperties entry, in display order.
+func (d *pdfDoc) setLayers(order []string, ids map[string]pdfId) {
+	d.ocgOrder, d.ocgIds = order, ids
 }
 
 const pdfUseNow = "<now?\b>"
@@ -153,6 +162,12 @@ func (d *pdfDoc) addObject(id pdfId, value []byte) (err error) {
 }
 
 func (d *pdfDoc) addStream(id pdfId, data []byte) (err error) {
+	return d.addStreamExt(id, data, pdfNix)
+}
+
+// addStreamExt is like addStream but allows extra dictionary entries
+// (e.g. for a Form XObject) to be inserted before /Length.
+func (d *pdfDoc) addStreamExt(id pdfId, data []byte, extra []byte) (err error) {
 	if err = d.startObject(id); err != nil {
 		return
 	}
@@ -166,11 +181,52 @@ func (d *pdfDoc) addStream(id pdfId, data []byte) (err error) {
 			data, filter = defl.Bytes(), "/Filter/FlateDecode"
 		}
 	}
-	err = d.write(pdfChunk("<</Length %v%s>>\nstream\n", len(data), filter),
+	err = d.write(pdfChunk("<<%s/Length %v%s>>\nstream\n", extra, len(data), filter),
 		data, pdfNewLine(data), []byte("endstream\nendobj\n"))
 	return
 }
 
+// addGradientResources writes a shading pattern object for g and a
+// resources dictionary exposing it as "/P1", for use by a single page
+// that paints its muffler with a gradient. If reuse is true, the
+// resources dictionary also exposes the shared snowman Form XObject, so
+// the page can still "Do" it. props, if non-nil, is appended as extra
+// dictionary entries (e.g. a "/Properties" entry for layers). It
+// returns the id of the new resources dictionary.
+func (d *pdfDoc) addGradientResources(g Gradient, matrixNums string, reuse bool, snowmanXObject pdfId, props []byte) (resources pdfId, err error) {
+	patternId := d.newId()
+	if err = d.addObject(patternId, []byte(patternDict(g, matrixNums))); err != nil {
+		return
+	}
+	resources = d.newId()
+	body := pdfChunk("<</ProcSet[/PDF]/Pattern<</P1 %v>>", patternId)
+	if reuse {
+		body = append(body, pdfChunk("/XObject<<%s %v>>", snowmanXObjectName, snowmanXObject)...)
+	}
+	body = append(body, props...)
+	body = append(body, []byte(">>\n")...)
+	err = d.addObject(resources, body)
+	return
+}
+
+// addFontResources writes a resources dictionary exposing the standard
+// Helvetica font as "/F1", for use by a single page that draws a
+// caption. If useXObject is true, the resources dictionary also exposes
+// the shared snowman Form XObject, so the page can still "Do" it. props,
+// if non-nil, is appended as extra dictionary entries (e.g. a
+// "/Properties" entry for layers).
+func (d *pdfDoc) addFontResources(fontId pdfId, useXObject bool, snowmanXObject pdfId, props []byte) (resources pdfId, err error) {
+	resources = d.newId()
+	body := pdfChunk("<</ProcSet[/PDF/Text]/Font<</F1 %v>>", fontId)
+	if useXObject {
+		body = append(body, pdfChunk("/XObject<<%s %v>>", snowmanXObjectName, snowmanXObject)...)
+	}
+	body = append(body, props...)
+	body = append(body, []byte(">>\n")...)
+	err = d.addObject(resources, body)
+	return
+}
+
 func (d *pdfDoc) addPage(id, contents, resources pdfId, chunk []byte) (err error) {
 	if err = d.startObject(id); err != nil {
 		return
@@ -216,7 +272,21 @@ func (d *pdfDoc) finish() (err error) {
 
 	// catalog object
 	catalogId := d.newId()
-	err = d.addObject(catalogId, pdfChunk("<</Type/Catalog/Pages %v>>", d.pagesId))
+	catalogChunk := pdfChunk("<</Type/Catalog/Pages %v", d.pagesId)
+	if len(d.ocgOrder) > 0 {
+		order := new(bytes.Buffer)
+		order.WriteString("[")
+		for _, name := range d.ocgOrder {
+			fmt.Fprint(order, d.ocgIds[name])
+			order.Write(pdfSP)
+		}
+		order.Truncate(order.Len() - 1)
+		order.WriteString("]")
+		catalogChunk = append(catalogChunk,
+			pdfChunk("/OCProperties<</OCGs %s/D<</Order %s>>>>", order, order)...)
+	}
+	catalogChunk = append(catalogChunk, []byte(">>")...)
+	err = d.addObject(catalogId, catalogChunk)
 	if err != nil {
 		return
 	}
@@ -307,3 +377,26 @@ LOOP:
 	buf.WriteString(">")
 	return buf.String()
 }
+
+// pdfTextStr encodes a string as a PDF literal string suitable for a
+// standard Type1 font using WinAnsiEncoding: printable ASCII and the
+// Latin-1 range shared with WinAnsiEncoding (U+00A0-U+00FF) pass
+// through as single bytes; anything else is replaced with "?".
+func pdfTextStr(str string) string {
+	buf := new(bytes.Buffer)
+	buf.WriteString("(")
+	for _, u := range str {
+		switch {
+		case u == '(' || u == ')' || u == '\\':
+			fmt.Fprintf(buf, "\\%c", u)
+		case u >= 32 && u < 127:
+			buf.WriteByte(byte(u))
+		case u >= 0xA0 && u <= 0xFF:
+			buf.WriteByte(byte(u))
+		default:
+			buf.WriteByte('?')
+		}
+	}
+	buf.WriteString(")")
+	return buf.String()
+}
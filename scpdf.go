@@ -35,23 +35,75 @@ const (
 
 const dfltPdfVersion = "1.4"
 const dfltWidth = 210 * 72 / 25.4
-const dfltHeight = 294 * 72 / 25.4
+const dfltHeight = 297 * 72 / 25.4
 
 var dfltMuffler = &color.NRGBA{255, 0, 0, 255}
 
 const stdScale = 0.6
 
+// SizeType represents the dimensions of a page, measured in PDF points.
+type SizeType struct {
+	Wd, Ht float64
+}
+
+// stdPageSizes is the registry of standard page sizes (in portrait
+// orientation) known to this package.
+var stdPageSizes = map[string]SizeType{
+	"A3":      {841.89, 1190.55},
+	"A4":      {dfltWidth, dfltHeight},
+	"A5":      {419.53, 595.28},
+	"Letter":  {612, 792},
+	"Legal":   {612, 1008},
+	"Tabloid": {792, 1224},
+}
+
+// PageSizes returns the registry of standard page sizes (in portrait
+// orientation) known to this package, keyed by name: "A3", "A4", "A5",
+// "Letter", "Legal", "Tabloid".
+func PageSizes() map[string]SizeType {
+	t := make(map[string]SizeType, len(stdPageSizes))
+	for k, v := range stdPageSizes {
+		t[k] = v
+	}
+	return t
+}
+
+// namedPageSize looks up a standard page size by name and applies the
+// given orientation ("P"/"Portrait" or "L"/"Landscape"; empty means
+// portrait), swapping Wd and Ht for landscape.
+func namedPageSize(name, orientation string) (size SizeType, err error) {
+	size, ok := stdPageSizes[name]
+	if !ok {
+		return size, fmt.Errorf("unknown page size name %q", name)
+	}
+	switch orientation {
+	case "", "P", "Portrait":
+		// portrait, as stored
+	case "L", "Landscape":
+		size.Wd, size.Ht = size.Ht, size.Wd
+	default:
+		return size, fmt.Errorf("illegal orientation %q", orientation)
+	}
+	return size, nil
+}
+
 // Doc represents an SC-oriented PDF document.
 type Doc struct {
-	width, height float64
-	pages         []page
-	frozen        bool
-	info          map[string]string
+	width, height   float64
+	pages           []page
+	frozen          bool
+	info            map[string]string
+	snowmanReuse    bool
+	snowmanReuseSet bool
+	layers          []string
 }
 
 type page struct {
-	muffler color.Color
-	scale   float64
+	muffler  color.Color
+	gradient Gradient
+	scale    float64
+	size     *SizeType
+	style    *SnowmanStyle
 }
 
 // Version returns the version of this package.
@@ -67,6 +119,15 @@ func NewWithSize(width, height float64) (*Doc, error) {
 	return d, err
 }
 
+// NewWithPageSize makes a new document with a standard named page size
+// ("A3", "A4", "A5", "Letter", "Legal", "Tabloid") and orientation
+// ("P"/"Portrait" or "L"/"Landscape").
+func NewWithPageSize(name, orientation string) (*Doc, error) {
+	d := &Doc{}
+	err := d.SetPageSizeNamed(name, orientation)
+	return d, err
+}
+
 // SetPageSize sets the width and the height (measured in PDF points) of
 // this document. The length values must be positive.
 func (d *Doc) SetPageSize(width, height float64) error {
@@ -79,6 +140,16 @@ func (d *Doc) SetPageSize(width, height float64) error {
 	return nil
 }
 
+// SetPageSizeNamed sets the page size of this document to a standard
+// named size and orientation; see PageSizes for the available names.
+func (d *Doc) SetPageSizeNamed(name, orientation string) error {
+	size, err := namedPageSize(name, orientation)
+	if err != nil {
+		return err
+	}
+	return d.SetPageSize(size.Wd, size.Ht)
+}
+
 // PageSize returns the width and height of this document.
 func (d *Doc) PageSize() (float64, float64) {
 	d.autoPageSize()
@@ -112,6 +183,80 @@ func (d *Doc) AddPage(muffler color.Color) error {
 	return d.AddPageScaled(muffler, 1)
 }
 
+// AddPageSized adds to this document a new page with the given muffler
+// color, scale, and a page size of its own (used for this page's
+// /MediaBox instead of the document's default page size).
+func (d *Doc) AddPageSized(muffler color.Color, scale float64, size SizeType) error {
+	if d.frozen {
+		return errFrozen()
+	} else if scale <= 0 {
+		return fmt.Errorf("illegal scale value (%.3g)", scale)
+	} else if muffler == nil {
+		return fmt.Errorf("illegal muffler value (nil)")
+	} else if size.Wd <= 0 || size.Ht <= 0 {
+		return fmt.Errorf("illegal page size (%.3gx%.3g)", size.Wd, size.Ht)
+	}
+	page := page{muffler: muffler, scale: scale * stdScale, size: &size}
+	d.pages = append(d.pages, page)
+	return nil
+}
+
+// AddPageGradient adds to this document a new page whose muffler is
+// painted with a two-color gradient (a LinearGradient or a
+// RadialGradient) instead of a flat color.
+func (d *Doc) AddPageGradient(m Gradient, scale float64) error {
+	if d.frozen {
+		return errFrozen()
+	} else if scale <= 0 {
+		return fmt.Errorf("illegal scale value (%.3g)", scale)
+	} else if m == nil {
+		return fmt.Errorf("illegal gradient value (nil)")
+	}
+	if c1, c2 := m.colors(); c1 == nil || c2 == nil {
+		return fmt.Errorf("illegal gradient value (nil color)")
+	}
+	page := page{gradient: m, scale: scale * stdScale}
+	d.pages = append(d.pages, page)
+	return nil
+}
+
+// AddPageStyled adds to this document a new page with the given
+// muffler color, scale, and a SnowmanStyle that selects which
+// anatomical features are drawn and an optional caption. AddPage and
+// AddPageScaled are equivalent to calling this method with DefaultStyle.
+func (d *Doc) AddPageStyled(muffler color.Color, scale float64, style SnowmanStyle) error {
+	if d.frozen {
+		return errFrozen()
+	} else if scale <= 0 {
+		return fmt.Errorf("illegal scale value (%.3g)", scale)
+	} else if muffler == nil {
+		return fmt.Errorf("illegal muffler value (nil)")
+	}
+	page := page{muffler: muffler, scale: scale * stdScale, style: &style}
+	d.pages = append(d.pages, page)
+	return nil
+}
+
+// SetSnowmanReuse toggles whether the fixed black-ink snowman drawing is
+// factored into a single shared Form XObject referenced by every page,
+// instead of being repeated in each page's content stream. This is on
+// by default, since it produces much smaller files for documents with
+// many pages; pass false to emit the old, fully self-contained pages.
+func (d *Doc) SetSnowmanReuse(reuse bool) error {
+	if d.frozen {
+		return errFrozen()
+	}
+	d.snowmanReuse, d.snowmanReuseSet = reuse, true
+	return nil
+}
+
+func (d *Doc) autoSnowmanReuse() bool {
+	if !d.snowmanReuseSet {
+		return true
+	}
+	return d.snowmanReuse
+}
+
 // SetDocInfo specifies several kinds of information of this document.
 // The input is given as a map of strings.
 //
@@ -156,23 +301,95 @@ func (d *Doc) WriteTo(wr io.Writer) (n int64, err error) {
 	if err != nil {
 		return int64(pd.pos), err
 	}
+	reuse := d.autoSnowmanReuse()
+	layers := d.layers
+	ocgIds := make(map[string]pdfId, len(layers))
+	for _, name := range layers {
+		id := pd.newId()
+		err = pd.addObject(id, pdfChunk("<</Type/OCG/Name%s>>", pdfStr(name)))
+		if err != nil {
+			return int64(pd.pos), err
+		}
+		ocgIds[name] = id
+	}
+	pd.setLayers(layers, ocgIds)
+	props := propertiesChunk(layers, ocgIds)
+
 	resources := pd.newId()
-	err = pd.addObject(resources, []byte("<</ProcSet[/PDF]>>\n"))
+	var snowmanXObject pdfId
+	resourceBody := []byte("<</ProcSet[/PDF]")
+	if reuse {
+		snowmanXObject = pd.newId()
+		resourceBody = append(resourceBody, pdfChunk("/XObject<<%s %v>>", snowmanXObjectName, snowmanXObject)...)
+	}
+	resourceBody = append(resourceBody, props...)
+	resourceBody = append(resourceBody, []byte(">>\n")...)
+	err = pd.addObject(resources, resourceBody)
+	if err != nil {
+		return int64(pd.pos), err
+	}
+	if reuse {
+		err = pd.addStreamExt(snowmanXObject, []byte(snowmanBodyCode(layers)),
+			[]byte("/Type/XObject/Subtype/Form/BBox[0 0 1 1]"))
+		if err != nil {
+			return int64(pd.pos), err
+		}
+	}
 
 	// pages
+	var fontId pdfId
+	ensureFont := func() (pdfId, error) {
+		if fontId == 0 {
+			fontId = pd.newId()
+			err := pd.addObject(fontId,
+				[]byte("<</Type/Font/Subtype/Type1/BaseFont/Helvetica/Encoding/WinAnsiEncoding>>"))
+			if err != nil {
+				return 0, err
+			}
+		}
+		return fontId, nil
+	}
 	for _, p := range d.pages {
 		buf.Reset()
-		cod, len := transformCode(d.width, d.height, p.scale)
-		fmt.Fprintln(buf, "q", cod)
-		fmt.Fprintf(buf, essentialCode(p, len))
+		width, height := d.width, d.height
+		if p.size != nil {
+			width, height = p.size.Wd, p.size.Ht
+		}
+		matrixNums, len := transformMatrix(width, height, p.scale)
+		fmt.Fprintln(buf, "q", matrixNums, "cm")
+		pageResources := resources
+		if p.gradient != nil {
+			pageResources, err = pd.addGradientResources(p.gradient, matrixNums, reuse, snowmanXObject, props)
+			if err != nil {
+				return int64(pd.pos), err
+			}
+			fmt.Fprint(buf, essentialCodeGradient(reuse, layers))
+		} else if p.style != nil {
+			useXObject := reuse && isFullStyle(*p.style)
+			if p.style.Caption != "" {
+				fid, ferr := ensureFont()
+				if ferr != nil {
+					return int64(pd.pos), ferr
+				}
+				pageResources, err = pd.addFontResources(fid, useXObject, snowmanXObject, props)
+				if err != nil {
+					return int64(pd.pos), err
+				}
+			}
+			fmt.Fprint(buf, essentialCodeStyled(p, *p.style, useXObject, layers))
+		} else if reuse {
+			fmt.Fprint(buf, essentialCodeReused(p, layers))
+		} else {
+			fmt.Fprintf(buf, essentialCode(p, len, layers))
+		}
 		fmt.Fprintln(buf, "Q")
 		contents := pd.newId()
 		err = pd.addStream(contents, buf.Bytes())
 		if err != nil {
 			return int64(pd.pos), err
 		}
-		err = pd.addPage(pd.newId(), contents, resources,
-			pdfChunk("/MediaBox[0 0 %s %s]", realStr(d.width), realStr(d.height)))
+		err = pd.addPage(pd.newId(), contents, pageResources,
+			pdfChunk("/MediaBox[0 0 %s %s]", realStr(width), realStr(height)))
 		if err != nil {
 			return int64(pd.pos), err
 		}
@@ -184,9 +401,18 @@ func (d *Doc) WriteTo(wr io.Writer) (n int64, err error) {
 }
 
 func transformCode(width, height, scale float64) (string, float64) {
+	nums, len := transformMatrix(width, height, scale)
+	return nums + " cm", len
+}
+
+// transformMatrix computes the six numbers of the PDF transform matrix
+// that centers and scales the 0-1 unit square snowman drawing on a page
+// of the given size, without the trailing "cm" operator -- this is also
+// the matrix needed to align a gradient pattern with that same drawing.
+func transformMatrix(width, height, scale float64) (string, float64) {
 	len := math.Max(width, height) * scale
 	ox, oy := (width-len)/2, (height-len)/2
-	s := fmt.Sprintf("%s 0 0 %s %s %s cm", realStr(len), realStr(len), realStr(ox), realStr(oy))
+	s := fmt.Sprintf("%s 0 0 %s %s %s", realStr(len), realStr(len), realStr(ox), realStr(oy))
 	return s, len
 }
 
@@ -216,7 +442,11 @@ func (d *Doc) String() string {
 	buf = append(buf, s...)
 	buf = append(buf, '[')
 	for _, p := range d.pages {
-		s := fmt.Sprintf("%+v*%.3g;", p.muffler, p.scale)
+		var m interface{} = p.muffler
+		if p.gradient != nil {
+			m = p.gradient
+		}
+		s := fmt.Sprintf("%+v*%.3g;", m, p.scale)
 		buf = append(buf, s...)
 	}
 	buf[len(buf)-1] = ']'
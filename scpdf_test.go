@@ -0,0 +1,97 @@
+// Copyright (c) 2018 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package scpdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"image/color"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+// streamObjectRe matches the header of an indirect object that carries a
+// stream, capturing its object id, its /Length and whether it is
+// FlateDecode-filtered, up to and including the "stream\n" keyword. None
+// of the objects scpdf ever emits nest a "<<...>>" dictionary inside one
+// that itself holds a stream, so a non-greedy match up to the first ">>"
+// is enough to find the dictionary's end.
+var streamObjectRe = regexp.MustCompile(`(\d+) 0 obj\n<<[^>]*?/Length (\d+)([^>]*?)>>\nstream\n`)
+
+// streamContents extracts, from a PDF file generated by this package, the
+// raw (decompressed, if FlateDecode-filtered) byte content of every
+// indirect object that carries a stream, keyed by object id.
+func streamContents(t *testing.T, bs []byte) map[string][]byte {
+	t.Helper()
+	out := make(map[string][]byte)
+	for _, loc := range streamObjectRe.FindAllSubmatchIndex(bs, -1) {
+		id := string(bs[loc[2]:loc[3]])
+		length, err := strconv.Atoi(string(bs[loc[4]:loc[5]]))
+		if err != nil {
+			t.Fatalf("object %s: bad /Length: %v", id, err)
+		}
+		raw := bs[loc[1] : loc[1]+length]
+		if bytes.Contains(bs[loc[6]:loc[7]], []byte("FlateDecode")) {
+			zr, err := zlib.NewReader(bytes.NewReader(raw))
+			if err != nil {
+				t.Fatalf("object %s: %v", id, err)
+			}
+			raw, err = ioutil.ReadAll(zr)
+			if err != nil {
+				t.Fatalf("object %s: %v", id, err)
+			}
+		}
+		out[id] = raw
+	}
+	return out
+}
+
+// TestSnowmanReuseXObject confirms, as requested when the shared
+// Form XObject was introduced, that the fixed black-ink snowman drawing
+// is emitted exactly once in the xref (as a single "/Subtype/Form"
+// object) and that every page's own content stream references it by
+// name rather than redrawing the body inline.
+func TestSnowmanReuseXObject(t *testing.T) {
+	d := Doc{}
+	for _, c := range []color.Color{
+		color.NRGBA{R: 255, A: 255},
+		color.NRGBA{G: 255, A: 255},
+		color.NRGBA{B: 255, A: 255},
+	} {
+		if err := d.AddPage(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bs, err := d.PdfBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	formRe := regexp.MustCompile(`/Subtype/Form`)
+	if n := len(formRe.FindAll(bs, -1)); n != 1 {
+		t.Fatalf("expected the shared snowman body to appear exactly once as a Form XObject, got %d", n)
+	}
+
+	contents := streamContents(t, bs)
+	pageRe := regexp.MustCompile(`/Type/Page/Contents (\d+) 0 R`)
+	pages := pageRe.FindAllSubmatch(bs, -1)
+	if len(pages) != 3 {
+		t.Fatalf("expected 3 pages, found %d", len(pages))
+	}
+	invokeRe := regexp.MustCompile(regexp.QuoteMeta(snowmanXObjectName) + ` Do`)
+	for _, pm := range pages {
+		id := string(pm[1])
+		body, ok := contents[id]
+		if !ok {
+			t.Fatalf("contents object %s not found", id)
+		}
+		if !invokeRe.Match(body) {
+			t.Errorf("page contents %s does not invoke the shared snowman XObject", id)
+		}
+	}
+}
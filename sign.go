@@ -0,0 +1,340 @@
+// Copyright (c) 2018 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package scpdf
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"regexp"
+	"strconv"
+)
+
+// sigContentsLen is the number of bytes reserved for the hex-encoded
+// /Contents entry of the signature dictionary. It must be wide enough
+// to hold a PKCS#7 SignedData blob for the signer's key together with
+// its certificate chain.
+const sigContentsLen = 8192
+
+// byteRangeFieldWidth is the fixed width (in decimal digits) used for
+// each number inside /ByteRange, so the array can be patched in place
+// after the digest is known without changing the file length.
+const byteRangeFieldWidth = 10
+
+// Sign generates the PDF content of this document, as with WriteTo, and
+// appends to it an attached digital signature (a detached PKCS#7
+// SignedData, as used by /SubFilter adbe.pkcs7.detached) covering the
+// whole file. The signed file is written to wr.
+//
+// The signature is added as a PDF incremental update: the original body
+// is left untouched, and a new revision carrying an /AcroForm, a /Sig
+// field and an updated trailer (with /Prev pointing at the original
+// startxref) is appended after it.
+func (d *Doc) Sign(wr io.Writer, key crypto.Signer, cert *x509.Certificate, chain []*x509.Certificate) (n int64, err error) {
+	base, err := d.PdfBytes()
+	if err != nil {
+		return
+	}
+	signed, err := SignAfterWriteTo(base, key, cert, chain)
+	if err != nil {
+		return
+	}
+	m, err := wr.Write(signed)
+	return int64(m), err
+}
+
+// SignAfterWriteTo signs an already-generated PDF file (the bytes
+// produced by Doc.WriteTo or Doc.PdfBytes) with the given signer,
+// certificate and certificate chain, and returns the signed file. See
+// Doc.Sign for the details of how the signature is embedded.
+func SignAfterWriteTo(base []byte, key crypto.Signer, cert *x509.Certificate, chain []*x509.Certificate) ([]byte, error) {
+	pagesId, prevStart, size, err := parseForSigning(base)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	buf.Write(base)
+	if buf.Len() == 0 || buf.Bytes()[buf.Len()-1] != '\n' {
+		buf.WriteByte('\n')
+	}
+
+	acroFormId, sigFieldId, sigDictId, catalogId := size, size+1, size+2, size+3
+	offset := make(map[int]int, 4)
+
+	addObj := func(id int, body string) {
+		offset[id] = buf.Len()
+		fmt.Fprintf(buf, "%d 0 obj\n%s\nendobj\n", id, body)
+	}
+
+	addObj(acroFormId, fmt.Sprintf("<</Fields[%d 0 R]/SigFlags 3>>", sigFieldId))
+	addObj(sigFieldId, fmt.Sprintf(
+		"<</Type/Annot/Subtype/Widget/FT/Sig/Rect[0 0 0 0]/F 132/T(Signature1)/V %d 0 R>>",
+		sigDictId))
+
+	// the signature dictionary is assembled from known-length pieces so
+	// that the byte offsets of /ByteRange and /Contents within the final
+	// buffer can be computed directly, instead of re-parsing the text.
+	offset[sigDictId] = buf.Len()
+	fmt.Fprintf(buf, "%d 0 obj\n<</Type/Sig/Filter/Adobe.PPKLite"+
+		"/SubFilter/adbe.pkcs7.detached/ByteRange", sigDictId)
+	byteRangeOffset := buf.Len()
+	fmt.Fprint(buf, zeroByteRangePlaceholder())
+	fmt.Fprint(buf, "/Contents<")
+	contentsOffset := buf.Len()
+	buf.Write(bytes.Repeat([]byte("0"), sigContentsLen*2))
+	fmt.Fprint(buf, ">>>\nendobj\n")
+
+	addObj(catalogId, fmt.Sprintf(
+		"<</Type/Catalog/Pages %d 0 R/AcroForm %d 0 R>>", pagesId, acroFormId))
+
+	// incremental xref table: only the newly added objects, which are
+	// contiguous (acroFormId .. catalogId)
+	xrefPos := buf.Len()
+	fmt.Fprintf(buf, "xref\n%d %d\n", acroFormId, catalogId-acroFormId+1)
+	for id := acroFormId; id <= catalogId; id++ {
+		fmt.Fprintf(buf, "%010d %05d n \n", offset[id], 0)
+	}
+	fmt.Fprintf(buf, "trailer\n<</Size %d/Root %d 0 R/Prev %d>>\nstartxref\n%d\n%%%%EOF\n",
+		catalogId+1, catalogId, prevStart, xrefPos)
+
+	out := buf.Bytes()
+
+	contentsEnd := contentsOffset + sigContentsLen*2
+	byteRange := [4]int{0, byteRangeOffset - 1, contentsEnd + 1, len(out) - contentsEnd - 1}
+	digest := sha256.New()
+	digest.Write(out[byteRange[0] : byteRange[0]+byteRange[1]])
+	digest.Write(out[byteRange[2] : byteRange[2]+byteRange[3]])
+
+	pkcs7, err := makePkcs7SignedData(digest.Sum(nil), key, cert, chain)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkcs7)*2 > sigContentsLen*2 {
+		return nil, fmt.Errorf("signature too large for the reserved /Contents space")
+	}
+
+	copy(out[byteRangeOffset:], formatByteRange(byteRange))
+	copy(out[contentsOffset:], hexEncode(pkcs7))
+	return out, nil
+}
+
+func zeroByteRangePlaceholder() string {
+	return formatByteRange([4]int{0, 0, 0, 0})
+}
+
+// formatByteRange renders a /ByteRange array with each number
+// right-padded to byteRangeFieldWidth digits with trailing spaces, so
+// that repeated calls always produce the same length.
+func formatByteRange(br [4]int) string {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('[')
+	for i, v := range br {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		fmt.Fprintf(buf, "%-*d", byteRangeFieldWidth, v)
+	}
+	buf.WriteByte(']')
+	return buf.String()
+}
+
+func hexEncode(data []byte) []byte {
+	const hex = "0123456789abcdef"
+	out := make([]byte, len(data)*2)
+	for i, b := range data {
+		out[2*i], out[2*i+1] = hex[b>>4], hex[b&0xF]
+	}
+	return out
+}
+
+// parseForSigning extracts, from a PDF file generated by this package,
+// the catalog's object id, the page tree's object id, the previous
+// startxref position and the object count (xref /Size) -- everything
+// SignAfterWriteTo needs in order to append an incremental update.
+//
+// The catalog body is matched tolerant of extra dictionary entries
+// (such as /OCProperties, added when Doc.EnableLayers is used, or
+// /AcroForm, added by a prior signature) instead of assuming the
+// catalog has exactly the two keys /Type and /Pages. The trailer is
+// likewise matched tolerant of its two possible shapes -- the original
+// trailer (with /Info and /ID) or an incremental update's trailer (with
+// /Prev) -- so that a file already carrying one or more signatures
+// (including ones produced by this same function) is recognized at its
+// latest revision, instead of silently falling back to the original one.
+func parseForSigning(base []byte) (pagesId, prevStart, size int, err error) {
+	trailerRe := regexp.MustCompile(
+		`(?s)trailer\n<</Size (\d+)/Root (\d+) 0 R.*?>>\nstartxref\n(\d+)\n%%EOF\n?`)
+	matches := trailerRe.FindAllSubmatch(base, -1)
+	if matches == nil {
+		return 0, 0, 0, fmt.Errorf("not a recognized scpdf PDF file (trailer not found)")
+	}
+	m := matches[len(matches)-1] // the most recent revision
+	size, _ = strconv.Atoi(string(m[1]))
+	rootId, _ := strconv.Atoi(string(m[2]))
+	prevStart, _ = strconv.Atoi(string(m[3]))
+
+	catalogRe := regexp.MustCompile(fmt.Sprintf(`(?s)%d 0 obj\n<<(.*?)>>\nendobj\n`, rootId))
+	cm := catalogRe.FindSubmatch(base)
+	if cm == nil {
+		return 0, 0, 0, fmt.Errorf("not a recognized scpdf PDF file (catalog not found)")
+	}
+	pagesRe := regexp.MustCompile(`/Pages (\d+) 0 R`)
+	pm := pagesRe.FindSubmatch(cm[1])
+	if pm == nil {
+		return 0, 0, 0, fmt.Errorf("not a recognized scpdf PDF file (catalog has no /Pages)")
+	}
+	pagesId, _ = strconv.Atoi(string(pm[1]))
+	return
+}
+
+//-------------------------------------- minimal CMS SignedData
+
+var (
+	oidData            = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidContentType     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSHA256          = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+type pkcs7Attribute struct {
+	Type  asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+type pkcs7IssuerAndSerial struct {
+	IssuerName   asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type pkcs7SignerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     pkcs7IssuerAndSerial
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   []pkcs7Attribute `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+}
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+}
+
+type pkcs7SignedDataInner struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      pkcs7ContentInfo
+	Certificates     []asn1.RawValue   `asn1:"optional,tag:0"`
+	SignerInfos      []pkcs7SignerInfo `asn1:"set"`
+}
+
+type pkcs7ContentInfoOuter struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+// makePkcs7SignedData builds a detached CMS SignedData structure (as
+// expected by /SubFilter adbe.pkcs7.detached) over a pre-computed
+// SHA-256 digest of the signed byte ranges.
+func makePkcs7SignedData(digest []byte, key crypto.Signer, cert *x509.Certificate, chain []*x509.Certificate) ([]byte, error) {
+	sigAlgOid, err := signatureAlgorithmOid(key)
+	if err != nil {
+		return nil, err
+	}
+
+	digestValue, err := asn1.Marshal(digest)
+	if err != nil {
+		return nil, err
+	}
+	contentTypeValue, err := asn1.Marshal(oidData)
+	if err != nil {
+		return nil, err
+	}
+	attrs := []pkcs7Attribute{
+		{Type: oidContentType, Value: asn1.RawValue{FullBytes: asSet(contentTypeValue)}},
+		{Type: oidMessageDigest, Value: asn1.RawValue{FullBytes: asSet(digestValue)}},
+	}
+
+	// the authenticated attributes are hashed as a SET OF Attribute, not
+	// with the implicit [0] context tag used when embedded in SignerInfo
+	signedAttrsForHash, err := asn1.MarshalWithParams(attrs, "set")
+	if err != nil {
+		return nil, err
+	}
+	toSign := sha256.Sum256(signedAttrsForHash)
+	sig, err := key.Sign(rand.Reader, toSign[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := make([]asn1.RawValue, 0, 1+len(chain))
+	certs = append(certs, asn1.RawValue{FullBytes: cert.Raw})
+	for _, c := range chain {
+		certs = append(certs, asn1.RawValue{FullBytes: c.Raw})
+	}
+
+	inner := pkcs7SignedDataInner{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidSHA256}},
+		ContentInfo:      pkcs7ContentInfo{ContentType: oidData},
+		Certificates:     certs,
+		SignerInfos: []pkcs7SignerInfo{{
+			Version: 1,
+			IssuerAndSerialNumber: pkcs7IssuerAndSerial{
+				IssuerName:   asn1.RawValue{FullBytes: cert.RawIssuer},
+				SerialNumber: cert.SerialNumber,
+			},
+			DigestAlgorithm:           pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+			AuthenticatedAttributes:   attrs,
+			DigestEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: sigAlgOid},
+			EncryptedDigest:           sig,
+		}},
+	}
+	innerBytes, err := asn1.Marshal(inner)
+	if err != nil {
+		return nil, err
+	}
+
+	outer := pkcs7ContentInfoOuter{
+		ContentType: oidSignedData,
+		Content: asn1.RawValue{
+			Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true,
+			Bytes: innerBytes,
+		},
+	}
+	return asn1.Marshal(outer)
+}
+
+func signatureAlgorithmOid(key crypto.Signer) (asn1.ObjectIdentifier, error) {
+	switch key.Public().(type) {
+	case *rsa.PublicKey:
+		return oidSHA256WithRSA, nil
+	case *ecdsa.PublicKey:
+		return oidECDSAWithSHA256, nil
+	default:
+		return nil, fmt.Errorf("unsupported signer key type %T", key.Public())
+	}
+}
+
+// asSet wraps an already-DER-encoded value's bytes into a SET OF
+// containing just that one value (used for single-valued attributes).
+func asSet(valueBytes []byte) []byte {
+	out, err := asn1.MarshalWithParams([]asn1.RawValue{{FullBytes: valueBytes}}, "set")
+	pdfSure(err == nil)
+	return out
+}
@@ -0,0 +1,171 @@
+// Copyright (c) 2018 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package scpdf
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"math/big"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// genTestCert creates a throwaway self-signed ECDSA P-256 certificate,
+// valid for one hour, for use as a signer in the tests below.
+func genTestCert(t *testing.T) (*ecdsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "scpdf test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, cert
+}
+
+// sigDictRe matches a signature dictionary's published /ByteRange and
+// /Contents, as written by SignAfterWriteTo.
+var sigDictRe = regexp.MustCompile(`/ByteRange\[\s*(\d+)\s+(\d+)\s+(\d+)\s+(\d+)\s*\]/Contents<([0-9a-fA-F]+)>`)
+
+// checkOneSignature recomputes the SHA-256 digest over the bytes named by
+// one signature's /ByteRange and confirms it matches the messageDigest
+// authenticated attribute inside its embedded PKCS#7 SignedData, and that
+// the SignedData's own signature verifies against that attribute set.
+func checkOneSignature(t *testing.T, signed []byte, m [][]byte) {
+	t.Helper()
+	atoi := func(b []byte) int {
+		n := 0
+		for _, c := range b {
+			n = n*10 + int(c-'0')
+		}
+		return n
+	}
+	br := [4]int{atoi(m[1]), atoi(m[2]), atoi(m[3]), atoi(m[4])}
+	pkcs7, err := hex.DecodeString(string(m[5]))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.New()
+	digest.Write(signed[br[0] : br[0]+br[1]])
+	digest.Write(signed[br[2] : br[2]+br[3]])
+	want := digest.Sum(nil)
+
+	var outer pkcs7ContentInfoOuter
+	if _, err := asn1.Unmarshal(pkcs7, &outer); err != nil {
+		t.Fatalf("unmarshal outer ContentInfo: %v", err)
+	}
+	var inner pkcs7SignedDataInner
+	if _, err := asn1.Unmarshal(outer.Content.Bytes, &inner); err != nil {
+		t.Fatalf("unmarshal inner SignedData: %v", err)
+	}
+	if len(inner.SignerInfos) != 1 {
+		t.Fatalf("expected 1 SignerInfo, got %d", len(inner.SignerInfos))
+	}
+	si := inner.SignerInfos[0]
+
+	var gotDigest []byte
+	for _, attr := range si.AuthenticatedAttributes {
+		if !attr.Type.Equal(oidMessageDigest) {
+			continue
+		}
+		var set []asn1.RawValue
+		if _, err := asn1.UnmarshalWithParams(attr.Value.FullBytes, &set, "set"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := asn1.Unmarshal(set[0].FullBytes, &gotDigest); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if gotDigest == nil {
+		t.Fatal("messageDigest authenticated attribute not found")
+	}
+	if !bytes.Equal(gotDigest, want) {
+		t.Fatalf("messageDigest attribute %x does not match the recomputed /ByteRange digest %x", gotDigest, want)
+	}
+
+	signedAttrsForHash, err := asn1.MarshalWithParams(si.AuthenticatedAttributes, "set")
+	if err != nil {
+		t.Fatal(err)
+	}
+	toSign := sha256.Sum256(signedAttrsForHash)
+
+	cert, err := x509.ParseCertificate(inner.Certificates[0].FullBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		t.Fatalf("unexpected public key type %T", cert.PublicKey)
+	}
+	if !ecdsa.VerifyASN1(pub, toSign[:], si.EncryptedDigest) {
+		t.Fatal("signature does not verify against its own authenticated attributes")
+	}
+}
+
+// TestSignRoundTrip signs a generated PDF and confirms the published
+// /ByteRange covers exactly the bytes whose SHA-256 the embedded PKCS#7
+// SignedData claims to sign over, and that the signature itself verifies.
+// It then signs the already-signed file a second time (the case that
+// once silently corrupted the first signature, see chunk0-3's follow-up
+// fix) and confirms both signatures remain independently valid and the
+// first signature's bytes are left untouched by the second.
+func TestSignRoundTrip(t *testing.T) {
+	d := Doc{}
+	if err := d.AddPage(dfltMuffler); err != nil {
+		t.Fatal(err)
+	}
+	base, err := d.PdfBytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, cert := genTestCert(t)
+
+	signed, err := SignAfterWriteTo(base, key, cert, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches := sigDictRe.FindAllSubmatch(signed, -1)
+	if len(matches) != 1 {
+		t.Fatalf("expected 1 signature, found %d", len(matches))
+	}
+	checkOneSignature(t, signed, matches[0])
+
+	twiceSigned, err := SignAfterWriteTo(signed, key, cert, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(twiceSigned, signed) {
+		t.Fatal("double-signing must append an incremental update, not rewrite the first signature")
+	}
+	matches = sigDictRe.FindAllSubmatch(twiceSigned, -1)
+	if len(matches) != 2 {
+		t.Fatalf("expected 2 signatures after double-signing, found %d", len(matches))
+	}
+	for _, m := range matches {
+		checkOneSignature(t, twiceSigned, m)
+	}
+}
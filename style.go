@@ -0,0 +1,38 @@
+// Copyright (c) 2018 Takayuki YATO (aka. "ZR")
+//   GitHub:   https://github.com/zr-tex8r
+//   Twitter:  @zr_tex8r
+// Distributed under the MIT License.
+
+package scpdf
+
+// MouthShape selects the shape of a snowman's mouth, as set by
+// SnowmanStyle.MouthShape.
+type MouthShape int
+
+const (
+	MouthSmile MouthShape = iota
+	MouthFrown
+	MouthNeutral
+)
+
+// SnowmanStyle controls which anatomical features of a snowman are
+// drawn, and an optional caption, for use with Doc.AddPageStyled.
+type SnowmanStyle struct {
+	Eyes, Mouth, Hat, Arms, Buttons, Snow bool
+	MouthShape                            MouthShape
+	Caption                               string
+}
+
+// DefaultStyle is the SnowmanStyle used (implicitly) by Doc.AddPage and
+// Doc.AddPageScaled: every feature on, a smiling mouth, and no caption.
+var DefaultStyle = SnowmanStyle{
+	Eyes: true, Mouth: true, Hat: true, Arms: true, Buttons: true, Snow: true,
+	MouthShape: MouthSmile,
+}
+
+// isFullStyle reports whether style draws exactly the same picture as
+// the fixed snowmanBodyCode drawing, so that the shared Form XObject can
+// be reused for it instead of drawing the body inline.
+func isFullStyle(style SnowmanStyle) bool {
+	return style == DefaultStyle
+}